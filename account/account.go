@@ -42,6 +42,13 @@ func SignBytes(chainID string, o Signable) []byte {
 	return buf.Bytes()
 }
 
+// Signer is the interface through which a Signable can be signed without the caller needing to
+// hold the private key material directly. Implementations include account/keystore.AddressSigner,
+// which signs by decrypting a passphrase-protected key on demand.
+type Signer interface {
+	SignBytes(chainID string, o Signable) ([]byte, error)
+}
+
 type Addressable interface {
 	// Get the 20 byte EVM address of this account
 	Address() Address
@@ -64,6 +71,9 @@ type Account interface {
 	StorageRoot() []byte
 	// The permission flags and roles for this account
 	Permissions() ptypes.AccountPermissions
+	// The human-readable name claimed by this account, or "" if it has not claimed one. See
+	// AliasIndex for the reverse-lookup from alias to Address.
+	Alias() string
 	// Obtain a deterministic serialisation of this account
 	// (i.e. update order and Go runtime independent)
 	Encode() []byte
@@ -73,7 +83,9 @@ type MutableAccount interface {
 	Account
 	// Set public key (needed for lazy initialisation), should also set the dependent address
 	SetPubKey(pubKey crypto.PubKey) MutableAccount
-	// Subtract amount from account balance (will panic if amount is greater than balance)
+	// Subtract amount from account balance (will panic if amount is greater than balance). Does
+	// not consider any vesting schedule; callers spending from an account that may implement
+	// VestingAccount should use SubtractFromBalanceChecked instead, which enforces it
 	SubtractFromBalance(amount uint64) MutableAccount
 	// Add amount to balance (will panic if amount plus balance is a uint64 overflow)
 	AddToBalance(amount uint64) MutableAccount
@@ -87,6 +99,10 @@ type MutableAccount interface {
 	SetPermissions(permissions ptypes.AccountPermissions) MutableAccount
 	// Get a pointer this account's AccountPermissions in order to mutate them
 	MutablePermissions() *ptypes.AccountPermissions
+	// Set this account's alias (will panic if alias is non-empty and fails ValidateAlias).
+	// SetAlias does not itself enforce global uniqueness of alias: callers backed by shared state
+	// should use SetAliasChecked, which consults an AliasIndex before calling SetAlias.
+	SetAlias(alias string) MutableAccount
 	// Create a complete copy of this MutableAccount that is itself mutable
 	Copy() MutableAccount
 }
@@ -103,6 +119,9 @@ type ConcreteAccount struct {
 	Sequence    uint64                    `json:"sequence"`
 	StorageRoot []byte                    `json:"storage_root"` // VM storage merkle root.
 	Permissions ptypes.AccountPermissions `json:"permissions"`
+	// Alias is a human-readable name claimed by this account, or "" if it has not claimed one.
+	// Uniqueness is enforced by an AliasIndex at SetAlias time, not by this type.
+	Alias string `json:"alias,omitempty"`
 }
 
 func NewConcreteAccount(pubKey crypto.PubKey) ConcreteAccount {
@@ -133,11 +152,7 @@ func (acc ConcreteAccount) MutableAccount() MutableAccount {
 }
 
 func (acc *ConcreteAccount) Encode() []byte {
-	w := new(bytes.Buffer)
-	var n int
-	var err error
-	AccountEncoder(acc, w, &n, &err)
-	return w.Bytes()
+	return concreteAccountWrapper{acc}.Encode()
 }
 
 func (acc *ConcreteAccount) Copy() *ConcreteAccount {
@@ -175,6 +190,7 @@ func AsConcreteAccount(account Account) ConcreteAccount {
 		Sequence:    account.Sequence(),
 		StorageRoot: account.StorageRoot(),
 		Permissions: account.Permissions(),
+		Alias:       account.Alias(),
 	}
 }
 
@@ -200,11 +216,17 @@ func AsAccount(account Account) Account {
 	return AsConcreteAccount(account).Account()
 }
 
-// Returns a MutableAccount by copying from account
+// Returns a MutableAccount by copying from account, preserving its concrete type via Copy() when
+// account already implements MutableAccount (e.g. a vestingAccountWrapper keeps its vesting
+// schedule) rather than rebuilding a plain ConcreteAccount from just the Account interface's
+// getters, which would silently drop any fields ConcreteAccount does not itself declare.
 func AsMutableAccount(account Account) MutableAccount {
 	if account == nil {
 		return nil
 	}
+	if mutable, ok := account.(MutableAccount); ok {
+		return mutable.Copy()
+	}
 	return AsConcreteAccount(account).MutableAccount()
 }
 
@@ -233,7 +255,15 @@ type concreteAccountWrapper struct {
 	*ConcreteAccount `json:"unwrap"`
 }
 
-var _ = wire.RegisterInterface(struct{ Account }{}, wire.ConcreteType{concreteAccountWrapper{}, 0x01})
+// This registers the pre-AccountRegistry go-wire interface dispatch for Account (a single leading
+// type byte, no version), so go-wire can still read and write values of the Account interface
+// directly. MigrateLegacyAccount does not use it: legacy blobs are bare ConcreteAccount structs
+// with no type byte. New code should go through AccountRegistry via
+// AccountEncoder/AccountDecoder/Decode instead of this interface directly.
+var _ = wire.RegisterInterface(struct{ Account }{},
+	wire.ConcreteType{concreteAccountWrapper{}, 0x01},
+	wire.ConcreteType{vestingAccountWrapper{}, 0x02},
+)
 
 var _ Account = concreteAccountWrapper{}
 
@@ -265,8 +295,31 @@ func (caw concreteAccountWrapper) Permissions() ptypes.AccountPermissions {
 	return caw.ConcreteAccount.Permissions
 }
 
+func (caw concreteAccountWrapper) Alias() string {
+	return caw.ConcreteAccount.Alias
+}
+
 func (caw concreteAccountWrapper) Encode() []byte {
-	return caw.ConcreteAccount.Encode()
+	bs, err := DefaultAccountRegistry.Encode(caw)
+	if err != nil {
+		// Every type that can produce a concreteAccountWrapper is registered by this package's
+		// own init(), so a registry miss here would be a programming error, not a runtime one
+		panic(fmt.Sprintf("could not encode account: %v", err))
+	}
+	return bs
+}
+
+// encodeRaw serialises the ConcreteAccount fields alone, with no envelope or type tag; it is the
+// Body that AccountRegistry.Encode wraps in an envelope
+func (caw concreteAccountWrapper) encodeRaw() []byte {
+	w := new(bytes.Buffer)
+	var n int
+	var err error
+	wire.WriteBinary(caw.ConcreteAccount, w, &n, &err)
+	if err != nil {
+		panic(fmt.Sprintf("could not encode ConcreteAccount: %v", err))
+	}
+	return w.Bytes()
 }
 
 func (caw concreteAccountWrapper) MarshalJSON() ([]byte, error) {
@@ -334,6 +387,16 @@ func (caw concreteAccountWrapper) MutablePermissions() *ptypes.AccountPermission
 	return &caw.ConcreteAccount.Permissions
 }
 
+func (caw concreteAccountWrapper) SetAlias(alias string) MutableAccount {
+	if alias != "" {
+		if err := ValidateAlias(alias); err != nil {
+			panic(fmt.Errorf("attempt to set invalid alias on account %s: %v", caw.ConcreteAccount.Address, err))
+		}
+	}
+	caw.ConcreteAccount.Alias = alias
+	return caw
+}
+
 func (caw concreteAccountWrapper) Copy() MutableAccount {
 	return concreteAccountWrapper{caw.ConcreteAccount.Copy()}
 }
@@ -342,12 +405,52 @@ func (caw concreteAccountWrapper) Copy() MutableAccount {
 //----------------------------------------------
 // Encoding/decoding
 
+// AccountEncoder and AccountDecoder encode/decode via DefaultAccountRegistry, so any Account
+// implementation registered with RegisterAccountType (currently ConcreteAccount and
+// ConcreteVestingAccount) round-trips behind a versioned {Version, TypeTag, Body} envelope. See
+// AccountRegistry for the dispatch this replaces go-wire's raw interface tag with.
 func AccountEncoder(o interface{}, w io.Writer, n *int, err *error) {
-	wire.WriteBinary(o.(*ConcreteAccount), w, n, err)
+	acc, ok := o.(Account)
+	if !ok {
+		*err = fmt.Errorf("AccountEncoder: %T does not implement Account", o)
+		return
+	}
+	bs, encErr := DefaultAccountRegistry.Encode(acc)
+	if encErr != nil {
+		*err = encErr
+		return
+	}
+	written, writeErr := w.Write(bs)
+	*n += written
+	*err = writeErr
 }
 
+// AccountDecoder reads exactly one account's envelope from r via AccountRegistry.DecodeReader, so
+// it may safely be used to decode an account embedded in a larger wire stream (e.g. as one field
+// of an enclosing struct): unlike reading r to EOF, it never consumes bytes belonging to whatever
+// follows this account in the stream.
 func AccountDecoder(r io.Reader, n *int, err *error) interface{} {
-	return wire.ReadBinary(&ConcreteAccount{}, r, 0, n, err)
+	cr := &countingReader{r: r}
+	acc, decErr := DefaultAccountRegistry.DecodeReader(cr)
+	*n += cr.n
+	if decErr != nil {
+		*err = decErr
+		return nil
+	}
+	return acc
+}
+
+// countingReader wraps an io.Reader, tallying the number of bytes read through it so a caller can
+// report how much of the underlying stream a bounded decode actually consumed.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	read, err := c.r.Read(p)
+	c.n += read
+	return read, err
 }
 
 var AccountCodec = wire.Codec{
@@ -355,24 +458,23 @@ var AccountCodec = wire.Codec{
 	Decode: AccountDecoder,
 }
 
+// Decode decodes accBytes as written by AccountEncoder (or Account.Encode()) into whichever
+// registered Account implementation its envelope TypeTag names, returning ErrUnknownAccountType
+// for a tag this process does not have registered.
 func Decode(accBytes []byte) (Account, error) {
-	ca, err := DecodeConcrete(accBytes)
-	if err != nil {
-		return nil, err
-	}
-	return ca.Account(), nil
+	return DefaultAccountRegistry.Decode(accBytes)
 }
 
+// DecodeConcrete decodes accBytes and requires the result to be a plain (non-vesting)
+// ConcreteAccount; use Decode for a value that may be any registered Account implementation
 func DecodeConcrete(accBytes []byte) (*ConcreteAccount, error) {
-	var n int
-	var err error
-	acc := AccountDecoder(bytes.NewBuffer(accBytes), &n, &err)
+	acc, err := Decode(accBytes)
 	if err != nil {
 		return nil, err
 	}
-	ca, ok := acc.(*ConcreteAccount)
+	caw, ok := acc.(concreteAccountWrapper)
 	if !ok {
 		return nil, fmt.Errorf("could not convert decoded account to *ConcreteAccount")
 	}
-	return ca, nil
+	return caw.ConcreteAccount, nil
 }
@@ -0,0 +1,86 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// MinAliasLength is the shortest alias ValidateAlias will accept
+	MinAliasLength = 3
+	// MaxAliasLength is the longest alias ValidateAlias will accept
+	MaxAliasLength = 63
+)
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ValidateAlias returns an error if alias is not a lowercase alphanumeric string (allowing '-'
+// and '_') of between MinAliasLength and MaxAliasLength characters.
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return fmt.Errorf("alias %q must be between %d and %d characters long", alias, MinAliasLength,
+			MaxAliasLength)
+	}
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("alias %q must contain only lowercase letters, digits, '-' and '_'", alias)
+	}
+	return nil
+}
+
+// AliasIndex is a reverse-lookup store mapping a human-readable alias to the Address of the
+// account that has claimed it, so that users can transact against a name instead of a raw
+// 20-byte Address.
+type AliasIndex interface {
+	// Put claims alias for address. Implementations are not required to check uniqueness
+	// themselves; see SetAliasChecked for the usual caller-side enforcement.
+	Put(alias string, address Address) error
+	// Get returns the Address that has claimed alias, or ok == false if no account has
+	Get(alias string) (address Address, ok bool)
+	// Delete releases alias so it may be claimed by another account
+	Delete(alias string) error
+}
+
+// AliasGetter is satisfied by state implementations that can resolve an alias to the Address that
+// has claimed it, mirroring Getter's role for full Account lookups, so RPC and CLI layers can
+// resolve e.g. `send --to alice` without reimplementing the index lookup.
+type AliasGetter interface {
+	GetAddressFromAlias(alias string) (Address, error)
+}
+
+// SetAliasChecked validates alias and checks against index that it is not already claimed by a
+// different account before setting it on mutable and recording the claim in index. Unlike
+// SetAlias, which panics on an invalid alias (a programming error), SetAliasChecked returns an
+// error since a collision against shared state is an expected possibility, not a bug.
+func SetAliasChecked(mutable MutableAccount, index AliasIndex, alias string) (MutableAccount, error) {
+	if err := ValidateAlias(alias); err != nil {
+		return nil, err
+	}
+	if existing, ok := index.Get(alias); ok && existing != mutable.Address() {
+		return nil, fmt.Errorf("alias %q is already claimed by account %s", alias, existing)
+	}
+	oldAlias := mutable.Alias()
+	mutable = mutable.SetAlias(alias)
+	if err := index.Put(alias, mutable.Address()); err != nil {
+		return nil, err
+	}
+	if oldAlias != "" && oldAlias != alias {
+		if err := index.Delete(oldAlias); err != nil {
+			return nil, err
+		}
+	}
+	return mutable, nil
+}
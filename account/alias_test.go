@@ -0,0 +1,113 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapAliasIndex is a minimal in-memory AliasIndex for testing SetAliasChecked without a real
+// state tree.
+type mapAliasIndex map[string]Address
+
+func (idx mapAliasIndex) Put(alias string, address Address) error {
+	idx[alias] = address
+	return nil
+}
+
+func (idx mapAliasIndex) Get(alias string) (Address, bool) {
+	address, ok := idx[alias]
+	return address, ok
+}
+
+func (idx mapAliasIndex) Delete(alias string) error {
+	delete(idx, alias)
+	return nil
+}
+
+func TestValidateAlias(t *testing.T) {
+	valid := []string{"abc", "alice", "alice-2", "alice_2"}
+	for _, alias := range valid {
+		assert.NoError(t, ValidateAlias(alias), alias)
+	}
+	// Longest accepted alias: exactly MaxAliasLength lowercase letters
+	longest := make([]byte, MaxAliasLength)
+	for i := range longest {
+		longest[i] = 'a'
+	}
+	assert.NoError(t, ValidateAlias(string(longest)))
+
+	invalid := []string{
+		"",          // too short
+		"ab",        // below MinAliasLength
+		"Alice",     // uppercase not allowed
+		"alice!",    // punctuation not allowed
+		"alice bob", // whitespace not allowed
+	}
+	for _, alias := range invalid {
+		assert.Error(t, ValidateAlias(alias), alias)
+	}
+
+	tooLong := make([]byte, MaxAliasLength+1)
+	for i := range tooLong {
+		tooLong[i] = 'a'
+	}
+	assert.Error(t, ValidateAlias(string(tooLong)))
+}
+
+func TestSetAliasChecked(t *testing.T) {
+	index := make(mapAliasIndex)
+	alice := NewConcreteAccountFromSecret("alice").MutableAccount()
+	bob := NewConcreteAccountFromSecret("bob").MutableAccount()
+
+	alice, err := SetAliasChecked(alice, index, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", alice.Alias())
+	addr, ok := index.Get("alice")
+	require.True(t, ok)
+	assert.Equal(t, alice.Address(), addr)
+
+	// Re-setting the same alias on the same account is a no-op, not a collision
+	alice, err = SetAliasChecked(alice, index, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", alice.Alias())
+
+	// A different account cannot claim an alias that is already taken
+	_, err = SetAliasChecked(bob, index, "alice")
+	assert.Error(t, err)
+
+	// An invalid alias is rejected before touching the index
+	_, err = SetAliasChecked(bob, index, "no")
+	assert.Error(t, err)
+	_, ok = index.Get("no")
+	assert.False(t, ok)
+
+	// Renaming releases the old alias so it can be claimed by someone else
+	alice, err = SetAliasChecked(alice, index, "alice2")
+	require.NoError(t, err)
+	assert.Equal(t, "alice2", alice.Alias())
+	_, ok = index.Get("alice")
+	assert.False(t, ok, "old alias should be released from the index after a rename")
+	addr, ok = index.Get("alice2")
+	require.True(t, ok)
+	assert.Equal(t, alice.Address(), addr)
+
+	bob, err = SetAliasChecked(bob, index, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", bob.Alias())
+}
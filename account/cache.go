@@ -0,0 +1,326 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	ptypes "github.com/hyperledger/burrow/permission/types"
+)
+
+// Backend is satisfied by the underlying account store that an AccountCache buffers writes for:
+// reads fall through to it via Getter, and Write flushes buffered mutations back to it.
+type Backend interface {
+	Getter
+	UpdateAccount(account Account) error
+	RemoveAccount(address Address) error
+}
+
+// AccountCache is a copy-on-write buffer over a Backend: reads are served from the backend the
+// first time an address is touched and cached thereafter, writes are held in memory until Write
+// is called, and a stack of per-field journal entries lets a caller cheaply try a batch of
+// mutations via Snapshot and unwind them with RevertToSnapshot, analogous to the EVM's need to
+// try a call frame and unwind it on REVERT or out-of-gas. GetAccount and UpdateAccount each still
+// copy the account via AsMutableAccount; a revert replays only the journal entries written since
+// the snapshot.
+type AccountCache struct {
+	backend Backend
+
+	accounts map[Address]*cacheEntry
+
+	journal        []journalEntry
+	validRevisions []revision
+	nextRevisionID int
+}
+
+type cacheEntry struct {
+	account MutableAccount
+	removed bool
+}
+
+type revision struct {
+	id           int
+	journalIndex int
+}
+
+// NewAccountCache returns an AccountCache reading through to, and (via Write) writing back to,
+// backend.
+func NewAccountCache(backend Backend) *AccountCache {
+	return &AccountCache{
+		backend:  backend,
+		accounts: make(map[Address]*cacheEntry),
+	}
+}
+
+// GetAccount returns the current value of the account at address, which may reflect mutations
+// buffered by UpdateAccount/RemoveAccount since the last Write, or nil if no such account exists.
+// The returned account is a copy of the cache's own entry, so the caller can never mutate through
+// it: any change must go back through UpdateAccount for the journal to see it.
+func (ac *AccountCache) GetAccount(address Address) (Account, error) {
+	entry, err := ac.entry(address)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.removed {
+		return nil, nil
+	}
+	return AsMutableAccount(entry.account), nil
+}
+
+// entry returns the cache entry for address, deep-copying it out of the backend (via
+// AsMutableAccount, which preserves concrete type via Copy()) the first time address is touched so
+// later mutation of the cached copy can never alias the backend's own value.
+func (ac *AccountCache) entry(address Address) (*cacheEntry, error) {
+	if entry, ok := ac.accounts[address]; ok {
+		return entry, nil
+	}
+	acc, err := ac.backend.GetAccount(address)
+	if err != nil {
+		return nil, err
+	}
+	var entry *cacheEntry
+	if acc != nil {
+		entry = &cacheEntry{account: AsMutableAccount(acc)}
+	}
+	ac.accounts[address] = entry
+	ac.journal = append(ac.journal, touchAccountChange{address: address})
+	return entry, nil
+}
+
+// UpdateAccount buffers updated as the new value for its Address, journalling the previous value
+// of each of Balance, Code, Sequence, StorageRoot, Permissions and Alias that changed so
+// RevertToSnapshot can unwind them. Other differences (PubKey, and a VestingAccount's delegation
+// amounts) are not journalled and are not undone by RevertToSnapshot.
+func (ac *AccountCache) UpdateAccount(updated Account) error {
+	address := updated.Address()
+	entry, err := ac.entry(address)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		entry = &cacheEntry{}
+		ac.accounts[address] = entry
+		ac.journal = append(ac.journal, createAccountChange{address: address})
+	} else if prev := entry.account; prev != nil {
+		if prev.Balance() != updated.Balance() {
+			ac.journal = append(ac.journal, balanceChange{address: address, prev: prev.Balance()})
+		}
+		if !bytes.Equal(prev.Code(), updated.Code()) {
+			ac.journal = append(ac.journal, codeChange{address: address, prev: prev.Code()})
+		}
+		if prev.Sequence() != updated.Sequence() {
+			ac.journal = append(ac.journal, sequenceChange{address: address, prev: prev.Sequence()})
+		}
+		if !bytes.Equal(prev.StorageRoot(), updated.StorageRoot()) {
+			ac.journal = append(ac.journal, storageRootChange{address: address, prev: prev.StorageRoot()})
+		}
+		if !reflect.DeepEqual(prev.Permissions(), updated.Permissions()) {
+			ac.journal = append(ac.journal, permissionsChange{address: address, prev: prev.Permissions()})
+		}
+		if prev.Alias() != updated.Alias() {
+			ac.journal = append(ac.journal, aliasChange{address: address, prev: prev.Alias()})
+		}
+	}
+	if entry.removed {
+		ac.journal = append(ac.journal, removeAccountChange{address: address, prevRemoved: true})
+	}
+	entry.account = AsMutableAccount(updated)
+	entry.removed = false
+	return nil
+}
+
+// RemoveAccount flags address as self-destructed: it reads as absent from GetAccount and, on
+// Write, is deleted from the backend.
+func (ac *AccountCache) RemoveAccount(address Address) error {
+	entry, err := ac.entry(address)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		entry = &cacheEntry{}
+		ac.accounts[address] = entry
+	}
+	if entry.removed {
+		return nil
+	}
+	ac.journal = append(ac.journal, removeAccountChange{address: address, prevRemoved: false})
+	entry.removed = true
+	return nil
+}
+
+// Snapshot returns an identifier that can later be passed to RevertToSnapshot to unwind every
+// mutation made since this call.
+func (ac *AccountCache) Snapshot() int {
+	id := ac.nextRevisionID
+	ac.nextRevisionID++
+	ac.validRevisions = append(ac.validRevisions, revision{id: id, journalIndex: len(ac.journal)})
+	return id
+}
+
+// RevertToSnapshot undoes every mutation made since the matching call to Snapshot, in O(journal
+// entries since that snapshot) rather than O(all accounts touched since the cache was created).
+// It panics if revisionID was never returned by Snapshot or has already been reverted past.
+func (ac *AccountCache) RevertToSnapshot(revisionID int) {
+	idx := sort.Search(len(ac.validRevisions), func(i int) bool {
+		return ac.validRevisions[i].id >= revisionID
+	})
+	if idx == len(ac.validRevisions) || ac.validRevisions[idx].id != revisionID {
+		panic(fmt.Errorf("account: revision %v does not exist or has already been reverted", revisionID))
+	}
+	journalIndex := ac.validRevisions[idx].journalIndex
+
+	for i := len(ac.journal) - 1; i >= journalIndex; i-- {
+		ac.journal[i].revert(ac)
+	}
+	ac.journal = ac.journal[:journalIndex]
+	ac.validRevisions = ac.validRevisions[:idx]
+}
+
+// Write flushes every buffered mutation to the backend: UpdateAccount for each updated or created
+// account, RemoveAccount for each removed one. The cache's journal is left intact, so Write does
+// not itself invalidate any outstanding snapshot.
+func (ac *AccountCache) Write() error {
+	for address, entry := range ac.accounts {
+		if entry == nil {
+			continue
+		}
+		if entry.removed {
+			if err := ac.backend.RemoveAccount(address); err != nil {
+				return err
+			}
+			continue
+		}
+		if entry.account == nil {
+			continue
+		}
+		if err := ac.backend.UpdateAccount(entry.account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//----------------------------------------------
+// journal
+
+// journalEntry is a single undoable field-level mutation recorded by AccountCache so
+// RevertToSnapshot can unwind precisely the entries written since a given Snapshot.
+type journalEntry interface {
+	revert(*AccountCache)
+}
+
+// touchAccountChange marks that address was first read into the cache at this point in the
+// journal; reverting it forgets the cache entry entirely so a later read goes back to the
+// backend, exactly as if it had never been touched.
+type touchAccountChange struct {
+	address Address
+}
+
+func (c touchAccountChange) revert(ac *AccountCache) {
+	delete(ac.accounts, c.address)
+}
+
+type createAccountChange struct {
+	address Address
+}
+
+func (c createAccountChange) revert(ac *AccountCache) {
+	delete(ac.accounts, c.address)
+}
+
+type balanceChange struct {
+	address Address
+	prev    uint64
+}
+
+func (c balanceChange) revert(ac *AccountCache) {
+	entry := ac.accounts[c.address]
+	cur := entry.account.Balance()
+	switch {
+	case cur > c.prev:
+		entry.account = entry.account.SubtractFromBalance(cur - c.prev)
+	case cur < c.prev:
+		entry.account = entry.account.AddToBalance(c.prev - cur)
+	}
+}
+
+type codeChange struct {
+	address Address
+	prev    Bytecode
+}
+
+func (c codeChange) revert(ac *AccountCache) {
+	ac.accounts[c.address].account = ac.accounts[c.address].account.SetCode(c.prev)
+}
+
+// sequenceChange reaches past MutableAccount's increment-only IncSequence and sets the underlying
+// concrete struct field directly; this is safe because concreteAccountWrapper and
+// vestingAccountWrapper are unexported types of this same package. storageRootChange,
+// permissionsChange and aliasChange below restore their field through the MutableAccount setters.
+type sequenceChange struct {
+	address Address
+	prev    uint64
+}
+
+func (c sequenceChange) revert(ac *AccountCache) {
+	switch acc := ac.accounts[c.address].account.(type) {
+	case concreteAccountWrapper:
+		acc.ConcreteAccount.Sequence = c.prev
+	case vestingAccountWrapper:
+		acc.ConcreteVestingAccount.Sequence = c.prev
+	}
+}
+
+type storageRootChange struct {
+	address Address
+	prev    []byte
+}
+
+func (c storageRootChange) revert(ac *AccountCache) {
+	entry := ac.accounts[c.address]
+	entry.account = entry.account.SetStorageRoot(c.prev)
+}
+
+type permissionsChange struct {
+	address Address
+	prev    ptypes.AccountPermissions
+}
+
+func (c permissionsChange) revert(ac *AccountCache) {
+	entry := ac.accounts[c.address]
+	entry.account = entry.account.SetPermissions(c.prev)
+}
+
+type aliasChange struct {
+	address Address
+	prev    string
+}
+
+func (c aliasChange) revert(ac *AccountCache) {
+	entry := ac.accounts[c.address]
+	entry.account = entry.account.SetAlias(c.prev)
+}
+
+type removeAccountChange struct {
+	address     Address
+	prevRemoved bool
+}
+
+func (c removeAccountChange) revert(ac *AccountCache) {
+	ac.accounts[c.address].removed = c.prevRemoved
+}
@@ -0,0 +1,202 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapBackend is a minimal in-memory Backend for testing AccountCache without a real state tree.
+type mapBackend map[Address]Account
+
+func (b mapBackend) GetAccount(address Address) (Account, error) {
+	return b[address], nil
+}
+
+func (b mapBackend) UpdateAccount(account Account) error {
+	b[account.Address()] = AsMutableAccount(account)
+	return nil
+}
+
+func (b mapBackend) RemoveAccount(address Address) error {
+	delete(b, address)
+	return nil
+}
+
+func TestAccountCache_SnapshotRevert(t *testing.T) {
+	acc := NewConcreteAccountFromSecret("cache")
+	acc.Balance = 100
+	backend := mapBackend{acc.Address: acc.Account()}
+
+	cache := NewAccountCache(backend)
+
+	mutable, err := cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	id := cache.Snapshot()
+
+	mutated := AsMutableAccount(mutable).AddToBalance(50)
+	require.NoError(t, cache.UpdateAccount(mutated))
+
+	got, err := cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(150), got.Balance())
+
+	cache.RevertToSnapshot(id)
+
+	got, err = cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), got.Balance())
+
+	// Backend is untouched until Write
+	backendAcc, err := backend.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), backendAcc.Balance())
+}
+
+func TestAccountCache_RemoveAccountRevert(t *testing.T) {
+	acc := NewConcreteAccountFromSecret("cache-remove")
+	backend := mapBackend{acc.Address: acc.Account()}
+	cache := NewAccountCache(backend)
+
+	id := cache.Snapshot()
+	require.NoError(t, cache.RemoveAccount(acc.Address))
+
+	got, err := cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	cache.RevertToSnapshot(id)
+
+	got, err = cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, acc.Address, got.Address())
+}
+
+func TestAccountCache_Write(t *testing.T) {
+	acc := NewConcreteAccountFromSecret("cache-write")
+	backend := mapBackend{acc.Address: acc.Account()}
+	cache := NewAccountCache(backend)
+
+	mutable, err := cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	require.NoError(t, cache.UpdateAccount(AsMutableAccount(mutable).AddToBalance(7)))
+	require.NoError(t, cache.Write())
+
+	backendAcc, err := backend.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), backendAcc.Balance())
+}
+
+// TestAccountCache_VestingAccountSurvivesRoundTrip pins down that a VestingAccount keeps its
+// vesting schedule (and so its SpendableBalance enforcement) intact after passing through
+// GetAccount, UpdateAccount and Write: the cache must not silently downgrade it to a plain
+// ConcreteAccount along the way.
+func TestAccountCache_VestingAccountSurvivesRoundTrip(t *testing.T) {
+	base := NewConcreteAccountFromSecret("cache-vesting")
+	base.Balance = 100
+	vacc := NewContinuousVestingAccount(base, 0, 100)
+	backend := mapBackend{base.Address: vacc.Account()}
+
+	cache := NewAccountCache(backend)
+
+	got, err := cache.GetAccount(base.Address)
+	require.NoError(t, err)
+	vesting, ok := got.(VestingAccount)
+	require.True(t, ok)
+	assert.Equal(t, uint64(100), vesting.OriginalVesting())
+	assert.Equal(t, uint64(50), vesting.SpendableBalance(50))
+
+	mutated := got.(MutableVestingAccount).TrackDelegation(50, 10)
+	require.NoError(t, cache.UpdateAccount(mutated))
+	require.NoError(t, cache.Write())
+
+	got, err = cache.GetAccount(base.Address)
+	require.NoError(t, err)
+	vesting, ok = got.(VestingAccount)
+	require.True(t, ok)
+	assert.Equal(t, uint64(10), vesting.DelegatedVesting())
+
+	backendAcc, err := backend.GetAccount(base.Address)
+	require.NoError(t, err)
+	_, ok = backendAcc.(VestingAccount)
+	require.True(t, ok)
+}
+
+// TestAccountCache_GetAccountDoesNotAliasEntry pins down that the MutableAccount handed back by
+// GetAccount is a copy: mutating it in place (the pattern TrackDelegation/TrackUndelegation
+// callers use directly, without going through AsMutableAccount) must not reach the cache's own
+// entry until UpdateAccount is called, otherwise UpdateAccount's prev-vs-updated diff compares a
+// value to itself and Snapshot/RevertToSnapshot cannot undo the mutation.
+func TestAccountCache_GetAccountDoesNotAliasEntry(t *testing.T) {
+	acc := NewConcreteAccountFromSecret("cache-alias")
+	acc.Balance = 100
+	backend := mapBackend{acc.Address: acc.Account()}
+
+	cache := NewAccountCache(backend)
+
+	mutable, err := cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	id := cache.Snapshot()
+
+	mutated := mutable.(MutableAccount).IncSequence()
+	require.NoError(t, cache.UpdateAccount(mutated))
+
+	got, err := cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), got.Sequence())
+
+	cache.RevertToSnapshot(id)
+
+	got, err = cache.GetAccount(acc.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), got.Sequence())
+}
+
+// BenchmarkAccountCache_SnapshotRevert exercises the journal-based try/revert path that EVM call
+// frames use via AccountCache.
+func BenchmarkAccountCache_SnapshotRevert(b *testing.B) {
+	acc := NewConcreteAccountFromSecret("bench-cache")
+	backend := mapBackend{acc.Address: acc.Account()}
+	cache := NewAccountCache(backend)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := cache.Snapshot()
+		mutable, _ := cache.GetAccount(acc.Address)
+		mutated := AsMutableAccount(mutable).AddToBalance(1)
+		_ = cache.UpdateAccount(mutated)
+		cache.RevertToSnapshot(id)
+	}
+}
+
+// BenchmarkGetMutableAccount_CloneEachCall exercises the pattern AccountCache replaces: a
+// GetMutableAccount read (which hands back the backend's own concreteAccountWrapper uncopied)
+// followed by a backend write that stores a shallow AsMutableAccount copy on every call, with no
+// way to unwind a speculative mutation.
+func BenchmarkGetMutableAccount_CloneEachCall(b *testing.B) {
+	acc := NewConcreteAccountFromSecret("bench-clone")
+	backend := mapBackend{acc.Address: acc.Account()}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mutable, _ := GetMutableAccount(backend, acc.Address)
+		mutated := mutable.AddToBalance(1)
+		_ = backend.UpdateAccount(mutated)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keystore
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hyperledger/burrow/account"
+)
+
+// addressCache maintains the set of addresses currently present in a keystore directory, refreshed
+// from disk on construction and kept in sync thereafter by watching dir for changes. This lets
+// KeyStore answer "do we have a key for this address" without a directory listing on every call,
+// and notice keys added or removed by other processes sharing the same directory.
+type addressCache struct {
+	dir string
+
+	mu        sync.RWMutex
+	addresses map[account.Address]bool
+	watcher   *fsnotify.Watcher
+}
+
+func newAddressCache(dir string) *addressCache {
+	ac := &addressCache{
+		dir:       dir,
+		addresses: make(map[account.Address]bool),
+	}
+	ac.scan()
+	ac.watch()
+	return ac
+}
+
+func (ac *addressCache) scan() {
+	entries, err := ioutil.ReadDir(ac.dir)
+	if err != nil {
+		// Directory may not exist yet (e.g. before the first StoreKey) - treat as empty
+		return
+	}
+	addresses := make(map[account.Address]bool, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		addrBytes, err := hex.DecodeString(entry.Name())
+		if err != nil {
+			continue
+		}
+		address, err := account.AddressFromBytes(addrBytes)
+		if err != nil {
+			continue
+		}
+		addresses[address] = true
+	}
+	ac.mu.Lock()
+	ac.addresses = addresses
+	ac.mu.Unlock()
+}
+
+// watch starts a best-effort background fsnotify watch on dir that rescans the directory on any
+// change. Failure to start the watch (e.g. dir does not exist yet) is not fatal: the cache simply
+// falls back to whatever it found on the last scan until the next explicit add/remove. The watch
+// and its goroutine run until Close stops them.
+func (ac *addressCache) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+	if err := watcher.Add(ac.dir); err != nil {
+		watcher.Close()
+		return
+	}
+	ac.mu.Lock()
+	ac.watcher = watcher
+	ac.mu.Unlock()
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				ac.scan()
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the background fsnotify watch started by watch, if one was successfully started.
+// Closing the underlying watcher closes its Events/Errors channels, which causes the watch
+// goroutine to return. Close is safe to call more than once.
+func (ac *addressCache) Close() {
+	ac.mu.Lock()
+	watcher := ac.watcher
+	ac.watcher = nil
+	ac.mu.Unlock()
+	if watcher != nil {
+		watcher.Close()
+	}
+}
+
+func (ac *addressCache) has(address account.Address) bool {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+	return ac.addresses[address]
+}
+
+func (ac *addressCache) add(address account.Address) {
+	ac.mu.Lock()
+	ac.addresses[address] = true
+	ac.mu.Unlock()
+}
+
+func (ac *addressCache) remove(address account.Address) {
+	ac.mu.Lock()
+	delete(ac.addresses, address)
+	ac.mu.Unlock()
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keystore stores account private keys on disk as passphrase-encrypted JSON files in
+// the Web3 Secret Storage format (scrypt-derived key, AES-128-CTR ciphertext, Keccak-256 MAC),
+// addressable by the account.Address they sign for.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/tendermint/go-crypto"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	version = 1
+
+	cipherName  = "aes-128-ctr"
+	kdfName     = "scrypt"
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+// Key is the decrypted form of a keystore entry: the private key together with the Address it
+// belongs to (derived from the key's public key, as elsewhere in the account package)
+type Key struct {
+	Address    account.Address
+	PrivateKey crypto.PrivKey
+}
+
+// encryptedKeyJSON is the on-disk Web3 Secret Storage representation of a Key
+type encryptedKeyJSON struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams cipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    scryptParams `json:"kdfparams"`
+	MAC          string       `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+type scryptParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// EncryptKey serialises key as passphrase-encrypted Web3 Secret Storage JSON, deriving the
+// AES/MAC key material from passphrase via scrypt with the given cost parameters
+func EncryptKey(key *Key, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	privBytes := key.PrivateKey.Bytes()
+	defer zero(privBytes)
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not read random salt: %v", err)
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive key from passphrase: %v", err)
+	}
+	defer zero(derivedKey)
+
+	encryptKey := derivedKey[:16]
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("could not read random iv: %v", err)
+	}
+	cipherText, err := aesCTRXOR(encryptKey, privBytes, iv)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt private key: %v", err)
+	}
+
+	mac := keccak256(derivedKey[16:32], cipherText)
+
+	return json.Marshal(encryptedKeyJSON{
+		Address: hex.EncodeToString(key.Address.Bytes()),
+		Crypto: cryptoJSON{
+			Cipher:       cipherName,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          kdfName,
+			KDFParams: scryptParams{
+				N:     scryptN,
+				R:     scryptR,
+				P:     scryptP,
+				DKLen: scryptDKLen,
+				Salt:  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac),
+		},
+		Version: version,
+	})
+}
+
+// DecryptKey recovers the Key contained in keyJSON using passphrase, verifying its MAC before
+// returning. The passphrase-derived key and any decrypted scratch buffers are zeroed before
+// DecryptKey returns.
+func DecryptKey(keyJSON []byte, passphrase string) (*Key, error) {
+	var encKey encryptedKeyJSON
+	if err := json.Unmarshal(keyJSON, &encKey); err != nil {
+		return nil, err
+	}
+	if encKey.Version != version {
+		return nil, fmt.Errorf("unsupported keystore version: %d", encKey.Version)
+	}
+	if encKey.Crypto.Cipher != cipherName {
+		return nil, fmt.Errorf("unsupported cipher: %s", encKey.Crypto.Cipher)
+	}
+	if encKey.Crypto.KDF != kdfName {
+		return nil, fmt.Errorf("unsupported kdf: %s", encKey.Crypto.KDF)
+	}
+
+	addrBytes, err := hex.DecodeString(encKey.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode address: %v", err)
+	}
+	address, err := account.AddressFromBytes(addrBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode address: %v", err)
+	}
+
+	salt, err := hex.DecodeString(encKey.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode salt: %v", err)
+	}
+	cipherText, err := hex.DecodeString(encKey.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode ciphertext: %v", err)
+	}
+	iv, err := hex.DecodeString(encKey.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode iv: %v", err)
+	}
+	wantMAC, err := hex.DecodeString(encKey.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode mac: %v", err)
+	}
+
+	p := encKey.Crypto.KDFParams
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, p.DKLen)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive key from passphrase: %v", err)
+	}
+	defer zero(derivedKey)
+
+	gotMAC := keccak256(derivedKey[16:32], cipherText)
+	if !hmacEqual(gotMAC, wantMAC) {
+		return nil, fmt.Errorf("could not decrypt key with given passphrase")
+	}
+
+	privBytes, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt private key: %v", err)
+	}
+	defer zero(privBytes)
+
+	priv, err := crypto.PrivKeyFromBytes(privBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode decrypted private key: %v", err)
+	}
+
+	return &Key{Address: address, PrivateKey: priv}, nil
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(block, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
+
+func keccak256(parts ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// hmacEqual compares two MACs in constant time to avoid leaking MAC bytes through early-exit
+// comparison timing
+func hmacEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// zero overwrites buf with zero bytes so key material does not linger on the heap
+func zero(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keystore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/go-crypto"
+)
+
+// testScryptN/testScryptP are far cheaper than DefaultScryptN/DefaultScryptP so the tests that
+// derive a key don't pay the ~100ms the production cost parameters are tuned for.
+const (
+	testScryptN = 1 << 12
+	testScryptP = 1
+)
+
+func testKey(t *testing.T) *Key {
+	priv := crypto.GenPrivKeyEd25519()
+	address, err := account.AddressFromBytes(priv.PubKey().Address())
+	require.NoError(t, err)
+	return &Key{Address: address, PrivateKey: priv}
+}
+
+func TestEncryptDecryptKey_RoundTrip(t *testing.T) {
+	key := testKey(t)
+
+	keyJSON, err := EncryptKey(key, "correct horse", testScryptN, testScryptP)
+	require.NoError(t, err)
+
+	decrypted, err := DecryptKey(keyJSON, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, key.Address, decrypted.Address)
+	assert.Equal(t, key.PrivateKey, decrypted.PrivateKey)
+}
+
+func TestDecryptKey_WrongPassphrase(t *testing.T) {
+	key := testKey(t)
+
+	keyJSON, err := EncryptKey(key, "correct horse", testScryptN, testScryptP)
+	require.NoError(t, err)
+
+	_, err = DecryptKey(keyJSON, "wrong passphrase")
+	assert.Error(t, err)
+}
+
+func TestDecryptKey_TamperedMAC(t *testing.T) {
+	key := testKey(t)
+
+	keyJSON, err := EncryptKey(key, "correct horse", testScryptN, testScryptP)
+	require.NoError(t, err)
+
+	var encKey encryptedKeyJSON
+	require.NoError(t, json.Unmarshal(keyJSON, &encKey))
+
+	macBytes, err := hex.DecodeString(encKey.Crypto.MAC)
+	require.NoError(t, err)
+	macBytes[0] ^= 0xff
+	encKey.Crypto.MAC = hex.EncodeToString(macBytes)
+
+	tamperedJSON, err := json.Marshal(encKey)
+	require.NoError(t, err)
+
+	_, err = DecryptKey(tamperedJSON, "correct horse")
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keystore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/tendermint/go-crypto"
+)
+
+// Default scrypt cost parameters, chosen to take roughly 100ms on contemporary hardware. Callers
+// storing many keys or running on constrained hardware may want to pass their own via
+// NewKeyStore.
+const (
+	DefaultScryptN = 1 << 18
+	DefaultScryptP = 1
+)
+
+// KeyStore stores encrypted private keys under dir, one file per Address, and serves them back
+// out decrypted only for the duration of the call that needs them.
+type KeyStore struct {
+	dir     string
+	scryptN int
+	scryptP int
+
+	mu    sync.Mutex
+	cache *addressCache
+}
+
+// NewKeyStore returns a KeyStore rooted at dir. It does not create dir; the first StoreKey does.
+// If dir already exists, a fsnotify watch on it keeps the address index in sync with keys added
+// or removed by other processes (e.g. a CLI import while a node is running); if it does not, no
+// watch is started and the index holds only keys stored through this KeyStore.
+func NewKeyStore(dir string, scryptN, scryptP int) *KeyStore {
+	ks := &KeyStore{
+		dir:     dir,
+		scryptN: scryptN,
+		scryptP: scryptP,
+	}
+	ks.cache = newAddressCache(dir)
+	return ks
+}
+
+func (ks *KeyStore) keyPath(address account.Address) string {
+	return filepath.Join(ks.dir, fmt.Sprintf("%X", address.Bytes()))
+}
+
+// Close stops the background directory watch started by NewKeyStore. A KeyStore must not be used
+// after Close.
+func (ks *KeyStore) Close() {
+	ks.cache.Close()
+}
+
+// StoreKey encrypts priv under passphrase and writes it to disk, returning the Address it is
+// addressable by (derived from priv's public key).
+func (ks *KeyStore) StoreKey(priv crypto.PrivKey, passphrase string) (account.Address, error) {
+	address, err := account.AddressFromBytes(priv.PubKey().Address())
+	if err != nil {
+		return account.Address{}, fmt.Errorf("could not derive address from private key: %v", err)
+	}
+	key := &Key{Address: address, PrivateKey: priv}
+
+	keyJSON, err := EncryptKey(key, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return account.Address{}, err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return account.Address{}, fmt.Errorf("could not create keystore directory %s: %v", ks.dir, err)
+	}
+	if err := ioutil.WriteFile(ks.keyPath(address), keyJSON, 0600); err != nil {
+		return account.Address{}, fmt.Errorf("could not write key file: %v", err)
+	}
+	ks.cache.add(address)
+	return address, nil
+}
+
+// DecryptKey decrypts a Web3 Secret Storage JSON blob (as produced by EncryptKey/StoreKey) with
+// passphrase and returns the contained private key. It does not consult the on-disk store, so it
+// can be used to import a key from elsewhere (e.g. a backup) before StoreKey persists it.
+func (ks *KeyStore) DecryptKey(jsonBlob []byte, passphrase string) (crypto.PrivKey, error) {
+	key, err := DecryptKey(jsonBlob, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// SignBytes decrypts the key stored for addr with passphrase and uses it to sign data. DecryptKey
+// already zeroes the scrypt-derived key and the raw decrypted key bytes it works with; the
+// resulting crypto.PrivKey retains its own copy of the key material behind go-crypto's opaque
+// PrivKey interface for the lifetime of this call, which this package has no way to zero itself.
+// The passphrase-derived key never leaves this call's goroutine.
+func (ks *KeyStore) SignBytes(addr account.Address, passphrase string, data []byte) ([]byte, error) {
+	if !ks.cache.has(addr) {
+		return nil, fmt.Errorf("no key found in keystore %s for address %s", ks.dir, addr)
+	}
+	ks.mu.Lock()
+	keyJSON, err := ioutil.ReadFile(ks.keyPath(addr))
+	ks.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file for address %s: %v", addr, err)
+	}
+
+	key, err := DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sig := key.PrivateKey.Sign(data)
+	return sig.Bytes(), nil
+}
+
+// AddressSigner binds a KeyStore, Address and passphrase together so that the bundle satisfies
+// account.Signer, letting callers sign without ever touching the private key themselves.
+type AddressSigner struct {
+	keyStore   *KeyStore
+	address    account.Address
+	passphrase string
+}
+
+// Signer returns an account.Signer that signs for address using passphrase via ks
+func (ks *KeyStore) Signer(address account.Address, passphrase string) *AddressSigner {
+	return &AddressSigner{keyStore: ks, address: address, passphrase: passphrase}
+}
+
+var _ account.Signer = &AddressSigner{}
+
+func (s *AddressSigner) SignBytes(chainID string, o account.Signable) ([]byte, error) {
+	return s.keyStore.SignBytes(s.address, s.passphrase, account.SignBytes(chainID, o))
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/go-crypto"
+)
+
+func newTestKeyStore(t *testing.T) (*KeyStore, func()) {
+	dir, err := ioutil.TempDir("", "keystore-test")
+	require.NoError(t, err)
+	ks := NewKeyStore(dir, testScryptN, testScryptP)
+	return ks, func() {
+		ks.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestKeyStore_StoreKeyAndSignBytes(t *testing.T) {
+	ks, cleanup := newTestKeyStore(t)
+	defer cleanup()
+
+	priv := crypto.GenPrivKeyEd25519()
+	address, err := ks.StoreKey(priv, "passphrase")
+	require.NoError(t, err)
+
+	data := []byte("sign me")
+	sig, err := ks.SignBytes(address, "passphrase", data)
+	require.NoError(t, err)
+	assert.Equal(t, priv.Sign(data).Bytes(), sig)
+}
+
+func TestKeyStore_SignBytes_WrongPassphrase(t *testing.T) {
+	ks, cleanup := newTestKeyStore(t)
+	defer cleanup()
+
+	priv := crypto.GenPrivKeyEd25519()
+	address, err := ks.StoreKey(priv, "passphrase")
+	require.NoError(t, err)
+
+	_, err = ks.SignBytes(address, "wrong passphrase", []byte("sign me"))
+	assert.Error(t, err)
+}
+
+func TestKeyStore_SignBytes_UnknownAddress(t *testing.T) {
+	ks, cleanup := newTestKeyStore(t)
+	defer cleanup()
+
+	priv := crypto.GenPrivKeyEd25519()
+	address, err := account.AddressFromBytes(priv.PubKey().Address())
+	require.NoError(t, err)
+
+	_, err = ks.SignBytes(address, "passphrase", []byte("sign me"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"bytes"
+	"fmt"
+
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+)
+
+// MigrateLegacyAccount decodes legacyBytes as a pre-AccountRegistry go-wire blob (a bare
+// wire.WriteBinary(*ConcreteAccount) struct, as produced by the old AccountEncoder, with no type
+// byte or envelope) and re-encodes the resulting Account under the current versioned envelope, as
+// produced by AccountEncoder/Account.Encode(). It is intended for one-off migration of
+// already-persisted state; new writes should never produce the legacy format.
+func MigrateLegacyAccount(legacyBytes []byte) ([]byte, error) {
+	acc, err := decodeLegacyAccount(legacyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode legacy account: %v", err)
+	}
+	return DefaultAccountRegistry.Encode(acc)
+}
+
+// legacyConcreteAccount is ConcreteAccount's field layout as it stood before the alias.go change
+// appended Alias. go-wire's binary encoding is positional with no field-presence prefix, so a
+// genuine legacy blob ends right after Permissions; decoding it straight into today's
+// ConcreteAccount (which expects an Alias string to follow) hits EOF on exactly the data this
+// function exists to migrate.
+type legacyConcreteAccount struct {
+	Address     Address                   `json:"address"`
+	PubKey      crypto.PubKey             `json:"pub_key"`
+	Balance     uint64                    `json:"balance"`
+	Code        Bytecode                  `json:"code"`
+	Sequence    uint64                    `json:"sequence"`
+	StorageRoot []byte                    `json:"storage_root"`
+	Permissions ptypes.AccountPermissions `json:"permissions"`
+}
+
+func decodeLegacyAccount(legacyBytes []byte) (Account, error) {
+	lca := new(legacyConcreteAccount)
+	var n int
+	var err error
+	wire.ReadBinary(lca, bytes.NewBuffer(legacyBytes), 0, &n, &err)
+	if err != nil {
+		return nil, err
+	}
+	ca := &ConcreteAccount{
+		Address:     lca.Address,
+		PubKey:      lca.PubKey,
+		Balance:     lca.Balance,
+		Code:        lca.Code,
+		Sequence:    lca.Sequence,
+		StorageRoot: lca.StorageRoot,
+		Permissions: lca.Permissions,
+	}
+	return concreteAccountWrapper{ca}, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/tendermint/go-wire"
+)
+
+// envelopeVersion is written as the first field of every envelope. It lets a future revision of
+// the envelope layout itself (as opposed to a registered account type's fields) be introduced
+// without breaking the ability to read data written by this version.
+const envelopeVersion = 1
+
+// ErrUnknownAccountType is returned by AccountRegistry.Decode when a payload's TypeTag has no
+// RegisterAccountType registration in this process, instead of panicking the way an unrecognised
+// go-wire interface tag byte would have.
+type ErrUnknownAccountType struct {
+	TypeTag uint32
+}
+
+func (e ErrUnknownAccountType) Error() string {
+	return fmt.Sprintf("unknown account type tag: %d", e.TypeTag)
+}
+
+// envelope is the versioned wrapper written around an encoded account so that fields can be added
+// to a registered type (or a new type registered altogether) without a hard fork of previously
+// written data: old readers that don't recognise TypeTag get ErrUnknownAccountType rather than
+// misinterpreting Body.
+type envelope struct {
+	Version uint32
+	TypeTag uint32
+	Body    []byte
+}
+
+// encodeEnvelope writes Version, TypeTag and len(Body) as varints ahead of Body itself, so
+// decodeEnvelopeReader knows exactly how many bytes belong to this envelope and never has to read
+// to EOF to find the end of Body.
+func encodeEnvelope(env envelope) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	buf := make([]byte, 0, len(env.Body)+3*binary.MaxVarintLen64)
+	n := binary.PutUvarint(scratch[:], uint64(env.Version))
+	buf = append(buf, scratch[:n]...)
+	n = binary.PutUvarint(scratch[:], uint64(env.TypeTag))
+	buf = append(buf, scratch[:n]...)
+	n = binary.PutUvarint(scratch[:], uint64(len(env.Body)))
+	buf = append(buf, scratch[:n]...)
+	buf = append(buf, env.Body...)
+	return buf
+}
+
+// decodeEnvelopeReader reads exactly one envelope from r: Version, TypeTag and a Body length,
+// each a varint, followed by that many bytes of Body. Because Body is length-prefixed rather than
+// read to EOF, a caller decoding an account embedded in a larger wire stream (as AccountDecoder
+// does via AccountCodec) is left with r positioned exactly after this envelope, with nothing
+// over-read from whatever follows it in the stream.
+func decodeEnvelopeReader(r io.Reader) (envelope, error) {
+	br := byteReader{r}
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return envelope{}, fmt.Errorf("could not read envelope version: %v", err)
+	}
+	typeTag, err := binary.ReadUvarint(br)
+	if err != nil {
+		return envelope{}, fmt.Errorf("could not read envelope type tag: %v", err)
+	}
+	bodyLen, err := binary.ReadUvarint(br)
+	if err != nil {
+		return envelope{}, fmt.Errorf("could not read envelope body length: %v", err)
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return envelope{}, fmt.Errorf("could not read envelope body: %v", err)
+	}
+	return envelope{Version: uint32(version), TypeTag: uint32(typeTag), Body: body}, nil
+}
+
+// byteReader adapts an io.Reader to io.ByteReader (as binary.ReadUvarint requires) with
+// single-byte reads, so reading a varint from it never buffers ahead past the varint's own bytes
+// into data that belongs to whatever the caller reads from the same stream next.
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	_, err := io.ReadFull(b.r, buf[:])
+	return buf[0], err
+}
+
+// rawEncodable is implemented by the wrapper types (concreteAccountWrapper,
+// vestingAccountWrapper, ...) that back a registered Account type. encodeRaw serialises just the
+// concrete struct's fields, with no envelope or type tag, to become an envelope's Body.
+type rawEncodable interface {
+	encodeRaw() []byte
+}
+
+// DecodeAccountFunc decodes the Body of an envelope (i.e. the output of the corresponding
+// rawEncodable.encodeRaw) back into an Account.
+type DecodeAccountFunc func(body []byte) (Account, error)
+
+// AccountRegistry dispatches Account encoding and decoding across every concrete implementation
+// registered with RegisterAccountType (ConcreteAccount and ConcreteVestingAccount as of this
+// package; future types such as ModuleAccount or ContractAccount register themselves the same
+// way) by a stable numeric TypeTag written as the second varint of the payload, right after the
+// envelope Version. This replaces
+// go-wire's single hard-coded interface dispatch, matching the pattern later Cosmos SDK releases
+// adopted when they moved off go-wire.
+type AccountRegistry struct {
+	decoders map[uint32]DecodeAccountFunc
+	tagOf    map[reflect.Type]uint32
+}
+
+// NewAccountRegistry returns an AccountRegistry with no types registered; see
+// DefaultAccountRegistry for the instance this package's own types are registered against.
+func NewAccountRegistry() *AccountRegistry {
+	return &AccountRegistry{
+		decoders: make(map[uint32]DecodeAccountFunc),
+		tagOf:    make(map[reflect.Type]uint32),
+	}
+}
+
+// RegisterAccountType associates tag with both directions of conversion for an Account
+// implementation: encoding dispatches on prototype's concrete Go type, decoding dispatches on tag
+// read from the envelope. tag must be stable across releases since it is what is persisted.
+func (r *AccountRegistry) RegisterAccountType(tag uint32, prototype Account, decode DecodeAccountFunc) {
+	r.tagOf[reflect.TypeOf(prototype)] = tag
+	r.decoders[tag] = decode
+}
+
+// Encode wraps acc's raw encoding in a versioned envelope carrying the TypeTag registered for
+// acc's concrete type.
+func (r *AccountRegistry) Encode(acc Account) ([]byte, error) {
+	tag, ok := r.tagOf[reflect.TypeOf(acc)]
+	if !ok {
+		return nil, fmt.Errorf("no registered account type tag for %T", acc)
+	}
+	re, ok := acc.(rawEncodable)
+	if !ok {
+		return nil, fmt.Errorf("%T does not support raw encoding", acc)
+	}
+	return encodeEnvelope(envelope{
+		Version: envelopeVersion,
+		TypeTag: tag,
+		Body:    re.encodeRaw(),
+	}), nil
+}
+
+// Decode reads accBytes as a versioned envelope and dispatches its Body to the DecodeAccountFunc
+// registered for its TypeTag, returning ErrUnknownAccountType if no such registration exists in
+// this process.
+func (r *AccountRegistry) Decode(accBytes []byte) (Account, error) {
+	return r.DecodeReader(bytes.NewReader(accBytes))
+}
+
+// DecodeReader reads exactly one envelope from rd - Version, TypeTag and a Body length, then that
+// many bytes of Body - and dispatches Body to the DecodeAccountFunc registered for TypeTag. Unlike
+// reading accBytes to EOF, this never consumes more of rd than the envelope itself, so a caller
+// decoding an account embedded in a larger wire stream (AccountDecoder, via AccountCodec) is left
+// with rd positioned exactly after this account.
+func (r *AccountRegistry) DecodeReader(rd io.Reader) (Account, error) {
+	env, err := decodeEnvelopeReader(rd)
+	if err != nil {
+		return nil, err
+	}
+	decode, ok := r.decoders[env.TypeTag]
+	if !ok {
+		return nil, ErrUnknownAccountType{TypeTag: env.TypeTag}
+	}
+	return decode(env.Body)
+}
+
+// DefaultAccountRegistry is the AccountRegistry backing AccountEncoder, AccountDecoder, and
+// Decode. Account implementations outside this package should call RegisterAccountType on it
+// (with a tag agreed out-of-band, since tags must not collide) before any of their values are
+// encoded or decoded.
+var DefaultAccountRegistry = NewAccountRegistry()
+
+func init() {
+	DefaultAccountRegistry.RegisterAccountType(1, concreteAccountWrapper{}, func(body []byte) (Account, error) {
+		ca := new(ConcreteAccount)
+		var n int
+		var err error
+		wire.ReadBinary(ca, bytes.NewBuffer(body), 0, &n, &err)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode ConcreteAccount: %v", err)
+		}
+		return concreteAccountWrapper{ca}, nil
+	})
+
+	DefaultAccountRegistry.RegisterAccountType(2, vestingAccountWrapper{}, func(body []byte) (Account, error) {
+		cva := new(ConcreteVestingAccount)
+		var n int
+		var err error
+		wire.ReadBinary(cva, bytes.NewBuffer(body), 0, &n, &err)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode ConcreteVestingAccount: %v", err)
+		}
+		return vestingAccountWrapper{cva}, nil
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/go-wire"
+)
+
+func TestAccountRegistry_RoundTripConcreteAccount(t *testing.T) {
+	acc := NewConcreteAccountFromSecret("foo")
+	acc.Balance = 100
+
+	encoded := acc.Account().Encode()
+	decoded, err := Decode(encoded)
+	require.NoError(t, err)
+
+	assert.Equal(t, acc.Address, decoded.Address())
+	assert.Equal(t, acc.Balance, decoded.Balance())
+
+	decodedConcrete, err := DecodeConcrete(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, acc.Address, decodedConcrete.Address)
+}
+
+func TestAccountRegistry_RoundTripVestingAccount(t *testing.T) {
+	base := NewConcreteAccountFromSecret("bar")
+	base.Balance = 1000
+	vacc := NewContinuousVestingAccount(base, 0, 1000)
+
+	encoded := vacc.Encode()
+	decoded, err := Decode(encoded)
+	require.NoError(t, err)
+
+	vestingDecoded, ok := decoded.(VestingAccount)
+	require.True(t, ok, "decoded account should implement VestingAccount")
+	assert.Equal(t, vacc.OriginalVestingAmount, vestingDecoded.OriginalVesting())
+	assert.Equal(t, uint64(500), vestingDecoded.SpendableBalance(500))
+}
+
+func TestAccountRegistry_UnknownTypeTag(t *testing.T) {
+	env := envelope{Version: envelopeVersion, TypeTag: 0xff, Body: []byte{}}
+	_, err := DefaultAccountRegistry.Decode(encodeEnvelope(env))
+	require.Error(t, err)
+	assert.Equal(t, ErrUnknownAccountType{TypeTag: 0xff}, err)
+}
+
+func TestMigrateLegacyAccount(t *testing.T) {
+	acc := NewConcreteAccountFromSecret("baz")
+	acc.Balance = 42
+
+	legacyBytes := legacyEncode(t, acc.Account())
+
+	migrated, err := MigrateLegacyAccount(legacyBytes)
+	require.NoError(t, err)
+
+	decoded, err := Decode(migrated)
+	require.NoError(t, err)
+	assert.Equal(t, acc.Address, decoded.Address())
+	assert.Equal(t, acc.Balance, decoded.Balance())
+	assert.Equal(t, "", decoded.Alias())
+}
+
+// TestLegacyConcreteAccountOmitsAlias pins down the structural relationship decodeLegacyAccount
+// depends on: legacyConcreteAccount must be ConcreteAccount's layout with exactly its trailing
+// Alias field removed. legacyEncode re-deriving its fields from ConcreteAccount each time this
+// file is edited would otherwise let legacyConcreteAccount silently drift back into step with
+// ConcreteAccount (e.g. if Alias were mistakenly added back to it), which would make
+// TestMigrateLegacyAccount pass without exercising a real legacy blob at all - exactly how the
+// previous version of this test went unnoticed.
+func TestLegacyConcreteAccountOmitsAlias(t *testing.T) {
+	modern := reflect.TypeOf(ConcreteAccount{})
+	legacy := reflect.TypeOf(legacyConcreteAccount{})
+	require.Equal(t, modern.NumField(), legacy.NumField()+1,
+		"legacyConcreteAccount must have exactly one fewer field than ConcreteAccount")
+	assert.Equal(t, "Alias", modern.Field(modern.NumField()-1).Name,
+		"the field legacyConcreteAccount omits must be ConcreteAccount's trailing Alias")
+}
+
+// legacyEncode writes acc the way the pre-AccountRegistry AccountEncoder did: a bare
+// wire.WriteBinary(*ConcreteAccount) *without* the Alias field later appended by alias.go, with no
+// leading type byte or envelope, to exercise MigrateLegacyAccount against the exact format it has
+// to read - a real legacy blob ends right after Permissions, one field short of today's
+// ConcreteAccount.
+func legacyEncode(t *testing.T, acc Account) []byte {
+	t.Helper()
+	ca := AsConcreteAccount(acc)
+	lca := &legacyConcreteAccount{
+		Address:     ca.Address,
+		PubKey:      ca.PubKey,
+		Balance:     ca.Balance,
+		Code:        ca.Code,
+		Sequence:    ca.Sequence,
+		StorageRoot: ca.StorageRoot,
+		Permissions: ca.Permissions,
+	}
+	w := new(bytes.Buffer)
+	var n int
+	var err error
+	wire.WriteBinary(lca, w, &n, &err)
+	require.NoError(t, err)
+	return w.Bytes()
+}
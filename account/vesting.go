@@ -0,0 +1,433 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/hyperledger/burrow/word"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+)
+
+// VestingType determines how OriginalVesting unlocks over time
+type VestingType uint8
+
+const (
+	// VestingTypeContinuous unlocks coins linearly between StartTime and EndTime
+	VestingTypeContinuous VestingType = iota
+	// VestingTypeDelayed keeps the whole OriginalVesting locked until EndTime, then unlocks it in one go
+	VestingTypeDelayed
+	// VestingTypePeriodic unlocks Periods of coins as each period's Length elapses from StartTime
+	VestingTypePeriodic
+)
+
+// Period is a single tranche of a periodic vesting schedule: Amount unlocks once Length seconds
+// have elapsed since the end of the previous period (or StartTime for the first period)
+type Period struct {
+	Length int64  `json:"length"`
+	Amount uint64 `json:"amount"`
+}
+
+// VestingAccount is satisfied by accounts whose Balance is subject to a vesting schedule. Callers
+// that need to spend from an Account should type-assert to VestingAccount and consult
+// SpendableBalance before calling SubtractFromBalance, since SubtractFromBalance itself only
+// guards against spending more than the total Balance.
+type VestingAccount interface {
+	Account
+	// OriginalVesting is the Balance that was locked under the vesting schedule at StartTime
+	OriginalVesting() uint64
+	// DelegatedFree is the amount of already-vested Balance currently delegated away
+	DelegatedFree() uint64
+	// DelegatedVesting is the amount of not-yet-vested Balance currently delegated away
+	DelegatedVesting() uint64
+	// SpendableBalance is the portion of Balance that is both vested and not delegated at blockTime
+	SpendableBalance(blockTime int64) uint64
+}
+
+// MutableVestingAccount is the mutable counterpart of VestingAccount, allowing delegation
+// bookkeeping to be updated as the account delegates and undelegates funds
+type MutableVestingAccount interface {
+	MutableAccount
+	VestingAccount
+	// TrackDelegation records that amount has been delegated at blockTime, attributing as much as
+	// possible to the still-locked (vesting) balance before falling back to already-vested (free)
+	// balance
+	TrackDelegation(blockTime int64, amount uint64) MutableVestingAccount
+	// TrackUndelegation reverses a prior delegation of amount, crediting DelegatedFree before
+	// DelegatedVesting
+	TrackUndelegation(amount uint64) MutableVestingAccount
+}
+
+// SubtractFromBalanceChecked is the vesting-aware spend chokepoint: if mutable is a VestingAccount
+// it rejects a subtraction that would dip into balance that is not yet both vested and
+// undelegated at blockTime, consulting SpendableBalance; for any other MutableAccount it behaves
+// exactly like SubtractFromBalance. Callers that spend from an account that may be a
+// VestingAccount (fee deduction, transfers, ...) should call this instead of SubtractFromBalance
+// directly, since SubtractFromBalance alone only guards against spending more than the total
+// Balance.
+func SubtractFromBalanceChecked(mutable MutableAccount, blockTime int64, amount uint64) (MutableAccount, error) {
+	if vacc, ok := mutable.(VestingAccount); ok {
+		if spendable := vacc.SpendableBalance(blockTime); amount > spendable {
+			return nil, fmt.Errorf("insufficient spendable balance: attempt to subtract %v from account %s "+
+				"but only %v is vested and undelegated at time %v", amount, mutable.Address(), spendable, blockTime)
+		}
+	}
+	return mutable.SubtractFromBalance(amount), nil
+}
+
+// -------------------------------------------------
+// ConcreteVestingAccount
+
+// ConcreteVestingAccount is the canonical serialisation of a vesting account. It embeds
+// ConcreteAccount so it carries the same Address/PubKey/Balance/Code/Sequence/StorageRoot/
+// Permissions fields, plus the schedule needed to compute how much of Balance is spendable
+type ConcreteVestingAccount struct {
+	ConcreteAccount
+	OriginalVestingAmount  uint64      `json:"original_vesting"`
+	DelegatedFreeAmount    uint64      `json:"delegated_free"`
+	DelegatedVestingAmount uint64      `json:"delegated_vesting"`
+	StartTime              int64       `json:"start_time"`
+	EndTime                int64       `json:"end_time"`
+	VestingType            VestingType `json:"vesting_type"`
+	// Periods is only populated when VestingType is VestingTypePeriodic
+	Periods []Period `json:"periods,omitempty"`
+}
+
+// NewContinuousVestingAccount locks balance at startTime and unlocks it linearly up to endTime
+func NewContinuousVestingAccount(base ConcreteAccount, startTime, endTime int64) *ConcreteVestingAccount {
+	return &ConcreteVestingAccount{
+		ConcreteAccount:       base,
+		OriginalVestingAmount: base.Balance,
+		StartTime:             startTime,
+		EndTime:               endTime,
+		VestingType:           VestingTypeContinuous,
+	}
+}
+
+// NewDelayedVestingAccount locks balance until endTime, at which point it unlocks in full
+func NewDelayedVestingAccount(base ConcreteAccount, endTime int64) *ConcreteVestingAccount {
+	return &ConcreteVestingAccount{
+		ConcreteAccount:       base,
+		OriginalVestingAmount: base.Balance,
+		EndTime:               endTime,
+		VestingType:           VestingTypeDelayed,
+	}
+}
+
+// NewPeriodicVestingAccount locks balance at startTime and unlocks each Period in turn as its
+// Length elapses, cumulatively from startTime
+func NewPeriodicVestingAccount(base ConcreteAccount, startTime int64, periods []Period) *ConcreteVestingAccount {
+	var endTime int64
+	var total uint64
+	endTime = startTime
+	for _, period := range periods {
+		endTime += period.Length
+		total += period.Amount
+	}
+	return &ConcreteVestingAccount{
+		ConcreteAccount:       base,
+		OriginalVestingAmount: total,
+		StartTime:             startTime,
+		EndTime:               endTime,
+		VestingType:           VestingTypePeriodic,
+		Periods:               periods,
+	}
+}
+
+// vestingAmount returns the portion of OriginalVestingAmount that is still locked at blockTime
+func (acc *ConcreteVestingAccount) vestingAmount(blockTime int64) uint64 {
+	switch acc.VestingType {
+	case VestingTypeDelayed:
+		if blockTime >= acc.EndTime {
+			return 0
+		}
+		return acc.OriginalVestingAmount
+
+	case VestingTypePeriodic:
+		if blockTime >= acc.EndTime {
+			return 0
+		}
+		var vested uint64
+		cursor := acc.StartTime
+		for _, period := range acc.Periods {
+			cursor += period.Length
+			if blockTime < cursor {
+				break
+			}
+			vested += period.Amount
+		}
+		return acc.OriginalVestingAmount - vested
+
+	default: // VestingTypeContinuous
+		if blockTime >= acc.EndTime {
+			return 0
+		}
+		if blockTime <= acc.StartTime {
+			return acc.OriginalVestingAmount
+		}
+		elapsed := blockTime - acc.StartTime
+		total := acc.EndTime - acc.StartTime
+		// big.Int avoids a uint64 overflow in elapsed * OriginalVestingAmount for large balances
+		// or long-running schedules, which would otherwise silently wrap the vested/locked split.
+		vested := new(big.Int).Mul(big.NewInt(elapsed), new(big.Int).SetUint64(acc.OriginalVestingAmount))
+		vested.Div(vested, big.NewInt(total))
+		return acc.OriginalVestingAmount - vested.Uint64()
+	}
+}
+
+// lockedCoins is the portion of vestingAmount that has not already been delegated away
+func (acc *ConcreteVestingAccount) lockedCoins(blockTime int64) uint64 {
+	vesting := acc.vestingAmount(blockTime)
+	if vesting <= acc.DelegatedVestingAmount {
+		return 0
+	}
+	return vesting - acc.DelegatedVestingAmount
+}
+
+func (acc *ConcreteVestingAccount) Encode() []byte {
+	return vestingAccountWrapper{acc}.Encode()
+}
+
+func (acc *ConcreteVestingAccount) Copy() *ConcreteVestingAccount {
+	accCopy := *acc
+	accCopy.Periods = make([]Period, len(acc.Periods))
+	copy(accCopy.Periods, acc.Periods)
+	return &accCopy
+}
+
+// Return as immutable VestingAccount
+func (acc ConcreteVestingAccount) Account() Account {
+	return vestingAccountWrapper{&acc}
+}
+
+// Return as MutableVestingAccount
+func (acc ConcreteVestingAccount) MutableAccount() MutableVestingAccount {
+	return vestingAccountWrapper{&acc}
+}
+
+// AsConcreteVestingAccount returns a mutable, serialisable ConcreteVestingAccount by copying from
+// account, or ok == false if account is not a VestingAccount
+func AsConcreteVestingAccount(account Account) (acc ConcreteVestingAccount, ok bool) {
+	vaw, ok := account.(vestingAccountWrapper)
+	if !ok {
+		return ConcreteVestingAccount{}, false
+	}
+	return *vaw.ConcreteVestingAccount, true
+}
+
+//----------------------------------------------
+// vestingAccountWrapper
+
+// vestingAccountWrapper wraps ConcreteVestingAccount to provide an immutable read-only view via
+// its implementation of VestingAccount and a mutable implementation via MutableVestingAccount
+type vestingAccountWrapper struct {
+	*ConcreteVestingAccount `json:"unwrap"`
+}
+
+var _ MutableVestingAccount = vestingAccountWrapper{}
+
+func (vaw vestingAccountWrapper) Address() Address {
+	return vaw.ConcreteVestingAccount.Address
+}
+
+func (vaw vestingAccountWrapper) PubKey() crypto.PubKey {
+	return vaw.ConcreteVestingAccount.PubKey
+}
+
+func (vaw vestingAccountWrapper) Balance() uint64 {
+	return vaw.ConcreteVestingAccount.Balance
+}
+
+func (vaw vestingAccountWrapper) Code() Bytecode {
+	return vaw.ConcreteVestingAccount.Code
+}
+
+func (vaw vestingAccountWrapper) Sequence() uint64 {
+	return vaw.ConcreteVestingAccount.Sequence
+}
+
+func (vaw vestingAccountWrapper) StorageRoot() []byte {
+	return vaw.ConcreteVestingAccount.StorageRoot
+}
+
+func (vaw vestingAccountWrapper) Permissions() ptypes.AccountPermissions {
+	return vaw.ConcreteVestingAccount.Permissions
+}
+
+func (vaw vestingAccountWrapper) Alias() string {
+	return vaw.ConcreteVestingAccount.Alias
+}
+
+func (vaw vestingAccountWrapper) Encode() []byte {
+	bs, err := DefaultAccountRegistry.Encode(vaw)
+	if err != nil {
+		panic(fmt.Sprintf("could not encode account: %v", err))
+	}
+	return bs
+}
+
+// encodeRaw serialises the ConcreteVestingAccount fields alone, with no envelope or type tag; it
+// is the Body that AccountRegistry.Encode wraps in an envelope
+func (vaw vestingAccountWrapper) encodeRaw() []byte {
+	w := new(bytes.Buffer)
+	var n int
+	var err error
+	wire.WriteBinary(vaw.ConcreteVestingAccount, w, &n, &err)
+	if err != nil {
+		panic(fmt.Sprintf("could not encode ConcreteVestingAccount: %v", err))
+	}
+	return w.Bytes()
+}
+
+func (vaw vestingAccountWrapper) OriginalVesting() uint64 {
+	return vaw.ConcreteVestingAccount.OriginalVestingAmount
+}
+
+func (vaw vestingAccountWrapper) DelegatedFree() uint64 {
+	return vaw.ConcreteVestingAccount.DelegatedFreeAmount
+}
+
+func (vaw vestingAccountWrapper) DelegatedVesting() uint64 {
+	return vaw.ConcreteVestingAccount.DelegatedVestingAmount
+}
+
+func (vaw vestingAccountWrapper) SpendableBalance(blockTime int64) uint64 {
+	locked := vaw.ConcreteVestingAccount.lockedCoins(blockTime)
+	if locked >= vaw.Balance() {
+		return 0
+	}
+	return vaw.Balance() - locked
+}
+
+// Account mutation via MutableAccount interface
+
+func (vaw vestingAccountWrapper) SetPubKey(pubKey crypto.PubKey) MutableAccount {
+	vaw.ConcreteVestingAccount.PubKey = pubKey
+	addressFromPubKey, err := AddressFromBytes(pubKey.Address())
+	if err != nil {
+		panic(fmt.Errorf("could not obtain address from public key: %v", pubKey))
+	}
+	if vaw.ConcreteVestingAccount.Address != addressFromPubKey {
+		panic(fmt.Errorf("attempt to set public key of account %s to %v, "+
+			"but that public key has address %s",
+			vaw.ConcreteVestingAccount.Address, pubKey, addressFromPubKey))
+	}
+	return vaw
+}
+
+// SubtractFromBalance only guards against spending more than the total Balance. Callers that want
+// to respect the vesting schedule must use the package-level SubtractFromBalanceChecked instead.
+func (vaw vestingAccountWrapper) SubtractFromBalance(amount uint64) MutableAccount {
+	if amount > vaw.Balance() {
+		panic(fmt.Errorf("insufficient funds: attempt to subtract %v from the balance of %s",
+			amount, &vaw.ConcreteVestingAccount.ConcreteAccount))
+	}
+	vaw.ConcreteVestingAccount.Balance -= amount
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) AddToBalance(amount uint64) MutableAccount {
+	if word.IsUint64SumOverflow(vaw.Balance(), amount) {
+		panic(fmt.Errorf("uint64 overflow: attempt to add %v to the balance of %s",
+			amount, &vaw.ConcreteVestingAccount.ConcreteAccount))
+	}
+	vaw.ConcreteVestingAccount.Balance += amount
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) SetCode(code []byte) MutableAccount {
+	vaw.ConcreteVestingAccount.Code = code
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) IncSequence() MutableAccount {
+	vaw.ConcreteVestingAccount.Sequence += 1
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) SetStorageRoot(storageRoot []byte) MutableAccount {
+	vaw.ConcreteVestingAccount.StorageRoot = storageRoot
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) SetPermissions(permissions ptypes.AccountPermissions) MutableAccount {
+	vaw.ConcreteVestingAccount.Permissions = permissions
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) MutablePermissions() *ptypes.AccountPermissions {
+	return &vaw.ConcreteVestingAccount.Permissions
+}
+
+func (vaw vestingAccountWrapper) SetAlias(alias string) MutableAccount {
+	if alias != "" {
+		if err := ValidateAlias(alias); err != nil {
+			panic(fmt.Errorf("attempt to set invalid alias on account %s: %v", vaw.ConcreteVestingAccount.Address, err))
+		}
+	}
+	vaw.ConcreteVestingAccount.Alias = alias
+	return vaw
+}
+
+func (vaw vestingAccountWrapper) Copy() MutableAccount {
+	return vestingAccountWrapper{vaw.ConcreteVestingAccount.Copy()}
+}
+
+// TrackDelegation records amount as delegated at blockTime, preferring to attribute it to the
+// still-locked (vesting) portion before falling back to already-vested (free) balance, matching
+// the standard vesting account semantics
+func (vaw vestingAccountWrapper) TrackDelegation(blockTime int64, amount uint64) MutableVestingAccount {
+	vesting := vaw.ConcreteVestingAccount.vestingAmount(blockTime)
+	delVesting := vaw.ConcreteVestingAccount.DelegatedVestingAmount
+
+	// x is the portion of amount that must come from the still-locked balance
+	var x uint64
+	if vesting > delVesting {
+		x = vesting - delVesting
+	}
+	if x > amount {
+		x = amount
+	}
+	y := amount - x
+
+	vaw.ConcreteVestingAccount.DelegatedVestingAmount += x
+	vaw.ConcreteVestingAccount.DelegatedFreeAmount += y
+	return vaw
+}
+
+// TrackUndelegation reverses a prior delegation of amount, crediting DelegatedFree before
+// DelegatedVesting, matching standard vesting account semantics (an undelegation is credited back
+// to the free bucket first, the mirror image of TrackDelegation attributing to the vesting bucket
+// first)
+func (vaw vestingAccountWrapper) TrackUndelegation(amount uint64) MutableVestingAccount {
+	delFree := vaw.ConcreteVestingAccount.DelegatedFreeAmount
+
+	x := amount
+	if x > delFree {
+		x = delFree
+	}
+	y := amount - x
+
+	vaw.ConcreteVestingAccount.DelegatedFreeAmount -= x
+	if y > vaw.ConcreteVestingAccount.DelegatedVestingAmount {
+		y = vaw.ConcreteVestingAccount.DelegatedVestingAmount
+	}
+	vaw.ConcreteVestingAccount.DelegatedVestingAmount -= y
+	return vaw
+}
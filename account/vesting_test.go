@@ -0,0 +1,134 @@
+// Copyright 2017 Monax Industries Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func continuousAccount(balance uint64, start, end int64) *ConcreteVestingAccount {
+	base := NewConcreteAccountFromSecret("vesting-continuous")
+	base.Balance = balance
+	return NewContinuousVestingAccount(base, start, end)
+}
+
+func TestContinuousVestingAccount_SpendableBalance(t *testing.T) {
+	acc := continuousAccount(1000, 0, 1000)
+
+	// Before StartTime, nothing has vested
+	assert.Equal(t, uint64(0), acc.MutableAccount().SpendableBalance(-1))
+	assert.Equal(t, uint64(0), acc.MutableAccount().SpendableBalance(0))
+
+	// Half way through the schedule, half has vested
+	assert.Equal(t, uint64(500), acc.MutableAccount().SpendableBalance(500))
+
+	// At and beyond EndTime, everything has vested
+	assert.Equal(t, uint64(1000), acc.MutableAccount().SpendableBalance(1000))
+	assert.Equal(t, uint64(1000), acc.MutableAccount().SpendableBalance(1001))
+}
+
+func TestDelayedVestingAccount_SpendableBalance(t *testing.T) {
+	base := NewConcreteAccountFromSecret("vesting-delayed")
+	base.Balance = 1000
+	acc := NewDelayedVestingAccount(base, 1000)
+
+	assert.Equal(t, uint64(0), acc.MutableAccount().SpendableBalance(0))
+	assert.Equal(t, uint64(0), acc.MutableAccount().SpendableBalance(999))
+	assert.Equal(t, uint64(1000), acc.MutableAccount().SpendableBalance(1000))
+}
+
+func TestPeriodicVestingAccount_SpendableBalance(t *testing.T) {
+	base := NewConcreteAccountFromSecret("vesting-periodic")
+	base.Balance = 300
+	acc := NewPeriodicVestingAccount(base, 0, []Period{
+		{Length: 100, Amount: 100},
+		{Length: 100, Amount: 100},
+		{Length: 100, Amount: 100},
+	})
+
+	assert.Equal(t, int64(300), acc.EndTime)
+	assert.Equal(t, uint64(300), acc.OriginalVestingAmount)
+
+	// Nothing vests until the first period's Length has elapsed
+	assert.Equal(t, uint64(0), acc.MutableAccount().SpendableBalance(0))
+	assert.Equal(t, uint64(0), acc.MutableAccount().SpendableBalance(99))
+
+	// A partial period does not unlock early: only completed periods count
+	assert.Equal(t, uint64(100), acc.MutableAccount().SpendableBalance(100))
+	assert.Equal(t, uint64(100), acc.MutableAccount().SpendableBalance(199))
+	assert.Equal(t, uint64(200), acc.MutableAccount().SpendableBalance(200))
+
+	// At and beyond EndTime, everything has vested
+	assert.Equal(t, uint64(300), acc.MutableAccount().SpendableBalance(300))
+	assert.Equal(t, uint64(300), acc.MutableAccount().SpendableBalance(1000))
+}
+
+func TestVestingAccount_TrackDelegation(t *testing.T) {
+	acc := continuousAccount(1000, 0, 1000).MutableAccount()
+
+	// At blockTime 0 nothing has vested, so the whole delegation is tracked as vesting
+	acc = acc.TrackDelegation(0, 100)
+	assert.Equal(t, uint64(100), acc.DelegatedVesting())
+	assert.Equal(t, uint64(0), acc.DelegatedFree())
+
+	// At blockTime 1000 everything has vested, so a further delegation is tracked as free
+	acc = acc.TrackDelegation(1000, 100)
+	assert.Equal(t, uint64(100), acc.DelegatedVesting())
+	assert.Equal(t, uint64(100), acc.DelegatedFree())
+}
+
+func TestVestingAccount_TrackUndelegation(t *testing.T) {
+	acc := continuousAccount(1000, 0, 1000).MutableAccount()
+	acc = acc.TrackDelegation(0, 100)
+	acc = acc.TrackDelegation(1000, 100)
+
+	// Undelegating less than DelegatedFree is credited entirely to DelegatedFree first
+	acc = acc.TrackUndelegation(50)
+	assert.Equal(t, uint64(100), acc.DelegatedVesting())
+	assert.Equal(t, uint64(50), acc.DelegatedFree())
+
+	// Undelegating the remainder spills over into DelegatedVesting
+	acc = acc.TrackUndelegation(100)
+	assert.Equal(t, uint64(50), acc.DelegatedVesting())
+	assert.Equal(t, uint64(0), acc.DelegatedFree())
+}
+
+func TestSubtractFromBalanceChecked_VestingAccount(t *testing.T) {
+	acc := continuousAccount(1000, 0, 1000).MutableAccount()
+
+	// At blockTime 500 only half of the balance has vested, so spending more than that is rejected
+	_, err := SubtractFromBalanceChecked(acc, 500, 501)
+	assert.Error(t, err)
+
+	// Spending up to (but not beyond) what has vested is allowed
+	mutated, err := SubtractFromBalanceChecked(acc, 500, 500)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(500), mutated.Balance())
+}
+
+func TestSubtractFromBalanceChecked_PlainAccount(t *testing.T) {
+	base := NewConcreteAccountFromSecret("non-vesting")
+	base.Balance = 100
+	acc := base.MutableAccount()
+
+	// A plain (non-vesting) account is unaffected by the vesting check: only the total Balance
+	// guard in SubtractFromBalance applies
+	mutated, err := SubtractFromBalanceChecked(acc, 0, 100)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), mutated.Balance())
+}